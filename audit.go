@@ -0,0 +1,190 @@
+package errorformator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/syslog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Field is a single caller-supplied key/value attached to an AuditEvent,
+// e.g. a request ID, user ID, or remote IP picked up by HTTP/gRPC
+// middleware.
+type Field struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// F builds a Field, shortening call sites like
+// FormatErrorCtx(ctx, err, errorformator.F("requestID", reqID)).
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// AuditEvent ties a returned error back to the call site that produced it
+// and, via Fields, the request that triggered it.
+type AuditEvent struct {
+	Time         time.Time `json:"time"`
+	BusinessCode int       `json:"businessCode"`
+	HTTPCode     int       `json:"httpCode"`
+	Package      string    `json:"package"`
+	FunctionName string    `json:"functionName"`
+	Line         string    `json:"line"`
+	Message      string    `json:"message"`
+	Fields       []Field   `json:"fields,omitempty"`
+}
+
+// AuditSink receives every AuditEvent FormatMsgCtx/FormatErrorCtx produce.
+// Emit must not block the caller for long; sinks that do slow I/O should
+// buffer or run it in the background themselves.
+type AuditSink interface {
+	Emit(ctx context.Context, event AuditEvent)
+}
+
+// emitAudit builds an AuditEvent and fans it out to every registered sink.
+// It is a no-op when no sink is registered.
+func (errorFormator *ErrorFormator) emitAudit(ctx context.Context, businessCode int, httpCode int, pkg string, funcName string, line string, message string, fields []Field) {
+	if len(errorFormator.Sinks) == 0 {
+		return
+	}
+	event := AuditEvent{
+		Time:         time.Now(),
+		BusinessCode: businessCode,
+		HTTPCode:     httpCode,
+		Package:      pkg,
+		FunctionName: funcName,
+		Line:         line,
+		Message:      message,
+		Fields:       fields,
+	}
+	for _, sink := range errorFormator.Sinks {
+		sink.Emit(ctx, event)
+	}
+}
+
+// LogSink writes AuditEvents through a stdlib *log.Logger.
+type LogSink struct {
+	logger *log.Logger
+}
+
+// NewLogSink builds a LogSink. A nil logger falls back to log.Default().
+func NewLogSink(logger *log.Logger) *LogSink {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &LogSink{logger: logger}
+}
+
+func (s *LogSink) Emit(ctx context.Context, event AuditEvent) {
+	s.logger.Printf("businessCode=%d httpCode=%d package=%s function=%s line=%s message=%q fields=%v",
+		event.BusinessCode, event.HTTPCode, event.Package, event.FunctionName, event.Line, event.Message, event.Fields)
+}
+
+// JSONFileSink appends one JSON object per line to a file, rotating it to
+// "<filename>.<unixnano>" once it grows past MaxBytes.
+type JSONFileSink struct {
+	Filename string
+	MaxBytes int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewJSONFileSink opens (creating if needed) filename for append and starts
+// tracking its size so Emit knows when to rotate.
+func NewJSONFileSink(filename string, maxBytes int64) (sink *JSONFileSink, err error) {
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return
+	}
+	sink = &JSONFileSink{
+		Filename: filename,
+		MaxBytes: maxBytes,
+		file:     f,
+		size:     info.Size(),
+	}
+	return
+}
+
+func (s *JSONFileSink) Emit(ctx context.Context, event AuditEvent) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	b = append(b, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.MaxBytes > 0 && s.size+int64(len(b)) > s.MaxBytes {
+		if err := s.rotate(); err != nil {
+			return
+		}
+	}
+	n, err := s.file.Write(b)
+	if err == nil {
+		s.size += int64(n)
+	}
+}
+
+func (s *JSONFileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	rotated := fmt.Sprintf("%s.%d", s.Filename, time.Now().UnixNano())
+	if err := os.Rename(s.Filename, rotated); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.Filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.file = f
+	s.size = 0
+	return nil
+}
+
+// Close closes the underlying file.
+func (s *JSONFileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// SyslogSink writes each AuditEvent as a JSON-encoded syslog error message.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+// NewSyslogSink dials the local syslog daemon, tagging every message with
+// tag.
+func NewSyslogSink(tag string) (sink *SyslogSink, err error) {
+	w, err := syslog.New(syslog.LOG_ERR|syslog.LOG_USER, tag)
+	if err != nil {
+		return
+	}
+	sink = &SyslogSink{writer: w}
+	return
+}
+
+func (s *SyslogSink) Emit(ctx context.Context, event AuditEvent) {
+	b, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	s.writer.Err(string(b))
+}
+
+// Close closes the underlying syslog connection.
+func (s *SyslogSink) Close() error {
+	return s.writer.Close()
+}
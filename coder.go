@@ -0,0 +1,148 @@
+package errorformator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// UnknownCode is the sentinel business code any unregistered code resolves to.
+const UnknownCode = 999999
+
+// Coder carries the metadata a business code resolves to: the HTTP status a
+// transport adapter should answer with, the message that is safe to show an
+// external caller, and a reference URL pointing at further documentation.
+type Coder interface {
+	Code() int
+	HTTPStatus() int
+	String() string
+	Reference() string
+}
+
+type defaultCoder struct {
+	code       int
+	httpStatus int
+	msg        string
+	reference  string
+}
+
+func (c *defaultCoder) Code() int       { return c.code }
+func (c *defaultCoder) HTTPStatus() int { return c.httpStatus }
+func (c *defaultCoder) String() string  { return c.msg }
+func (c *defaultCoder) Reference() string {
+	return c.reference
+}
+
+// NewCoder builds a Coder from its four fields. Most call sites should use
+// Register/MustRegister with the result rather than holding onto it directly.
+func NewCoder(code int, httpStatus int, msg string, reference string) Coder {
+	return &defaultCoder{code: code, httpStatus: httpStatus, msg: msg, reference: reference}
+}
+
+var unknownCoder = NewCoder(UnknownCode, 500, "unknown error", "")
+
+// registryEntry tracks whether a registered Coder came from an explicit
+// Register call or from registerDefault's auto-registration, so a later
+// explicit Register can still overwrite an auto entry instead of losing to
+// whichever one happened to run first.
+type registryEntry struct {
+	coder Coder
+	auto  bool
+}
+
+var (
+	coderRegistryMu sync.RWMutex
+	coderRegistry   = map[int]*registryEntry{
+		UnknownCode: {coder: unknownCoder},
+	}
+)
+
+// Register adds coder to the global registry. It is an error to register the
+// reserved UnknownCode or to register a code that already has an explicitly
+// registered Coder; it silently overwrites one that only got there through
+// registerDefault's auto-registration, since explicit Register calls always
+// take precedence over an auto default regardless of call order.
+func Register(coder Coder) error {
+	coderRegistryMu.Lock()
+	defer coderRegistryMu.Unlock()
+	if coder.Code() == UnknownCode {
+		return fmt.Errorf("errorformator: code %d is reserved for unknown errors", UnknownCode)
+	}
+	if entry, ok := coderRegistry[coder.Code()]; ok && !entry.auto {
+		return fmt.Errorf("errorformator: code %d is already registered", coder.Code())
+	}
+	coderRegistry[coder.Code()] = &registryEntry{coder: coder}
+	return nil
+}
+
+// MustRegister is like Register but panics if coder cannot be registered.
+func MustRegister(coder Coder) {
+	if err := Register(coder); err != nil {
+		panic(err)
+	}
+}
+
+// registerDefault stores a Coder for code the first time it is observed,
+// letting an explicit Register call made later or earlier always win.
+func registerDefault(code int, httpStatus int, msg string) Coder {
+	coderRegistryMu.Lock()
+	defer coderRegistryMu.Unlock()
+	if entry, ok := coderRegistry[code]; ok {
+		return entry.coder
+	}
+	coder := NewCoder(code, httpStatus, msg, "")
+	coderRegistry[code] = &registryEntry{coder: coder, auto: true}
+	return coder
+}
+
+// lookupCoder returns the Coder registered for code, falling back to the
+// unknown sentinel when nothing has claimed it.
+func lookupCoder(code int) Coder {
+	coderRegistryMu.RLock()
+	defer coderRegistryMu.RUnlock()
+	if entry, ok := coderRegistry[code]; ok {
+		return entry.coder
+	}
+	return unknownCoder
+}
+
+// ParseCoder recovers the Coder embedded in err by a prior FormatMsg/FormatError
+// call, falling back to the unknown sentinel when err carries no business code.
+func ParseCoder(err error) Coder {
+	if err == nil {
+		return unknownCoder
+	}
+	if formatted, ok := AsFormatted(err); ok {
+		return formatted.Coder
+	}
+	code, ok := parseWireCode(err.Error())
+	if !ok {
+		return unknownCoder
+	}
+	return lookupCoder(code)
+}
+
+// parseWireCode extracts the business code embedded by FormatMsg/FormatError
+// in the "<sep><httpCode>:<code><sep><msg>" wire format.
+func parseWireCode(s string) (code int, ok bool) {
+	if len(s) == 0 {
+		return 0, false
+	}
+	sep := s[0]
+	rest := s[1:]
+	sepIdx := strings.IndexByte(rest, sep)
+	if sepIdx < 0 {
+		return 0, false
+	}
+	head := rest[:sepIdx]
+	colonIdx := strings.IndexByte(head, ':')
+	if colonIdx < 0 {
+		return 0, false
+	}
+	code, err := strconv.Atoi(head[colonIdx+1:])
+	if err != nil {
+		return 0, false
+	}
+	return code, true
+}
@@ -0,0 +1,85 @@
+package errorformator
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegisterRejectsUnknownCode(t *testing.T) {
+	if err := Register(NewCoder(UnknownCode, 500, "nope", "")); err == nil {
+		t.Fatalf("Register(UnknownCode) should fail, got nil")
+	}
+}
+
+func TestRegisterRejectsDuplicateExplicitRegistration(t *testing.T) {
+	code := 410001
+	if err := Register(NewCoder(code, 400, "first", "")); err != nil {
+		t.Fatalf("first Register: %v", err)
+	}
+	if err := Register(NewCoder(code, 401, "second", "")); err == nil {
+		t.Fatalf("second explicit Register for the same code should fail, got nil")
+	}
+	if got := lookupCoder(code).HTTPStatus(); got != 400 {
+		t.Fatalf("lookupCoder(%d).HTTPStatus() = %d, want 400 (first registration should win)", code, got)
+	}
+}
+
+func TestRegisterOverwritesAutoRegisteredCoder(t *testing.T) {
+	code := 410002
+	auto := registerDefault(code, 500, "raw message")
+	if auto.HTTPStatus() != 500 {
+		t.Fatalf("registerDefault HTTPStatus() = %d, want 500", auto.HTTPStatus())
+	}
+
+	if err := Register(NewCoder(code, 418, "explicit human message", "https://example.com/410002")); err != nil {
+		t.Fatalf("Register over an auto-registered code should succeed, got: %v", err)
+	}
+
+	got := lookupCoder(code)
+	if got.HTTPStatus() != 418 || got.String() != "explicit human message" {
+		t.Fatalf("lookupCoder(%d) = %+v, want the explicit registration to win", code, got)
+	}
+
+	// A second explicit Register should now be rejected, since the entry is
+	// no longer an auto default.
+	if err := Register(NewCoder(code, 500, "third", "")); err == nil {
+		t.Fatalf("Register over an already-explicit entry should fail, got nil")
+	}
+}
+
+func TestMustRegisterPanicsOnDuplicate(t *testing.T) {
+	code := 410003
+	MustRegister(NewCoder(code, 400, "first", ""))
+
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("MustRegister should panic on a duplicate explicit registration")
+		}
+	}()
+	MustRegister(NewCoder(code, 400, "dup", ""))
+}
+
+func TestParseCoderRoundTripsThroughWireFormat(t *testing.T) {
+	formator, err := NewWithStore(NewNoopStore())
+	if err != nil {
+		t.Fatalf("NewWithStore: %v", err)
+	}
+	code := 410004
+	coder := NewCoder(code, 409, "conflict", "https://example.com/410004")
+	MustRegister(coder)
+
+	wireErr := formator.FormatMsg("boom", coder)
+	got := ParseCoder(wireErr)
+	if got.Code() != code || got.HTTPStatus() != 409 {
+		t.Fatalf("ParseCoder(wire error) = %+v, want code %d httpStatus 409", got, code)
+	}
+}
+
+func TestParseCoderFallsBackToUnknown(t *testing.T) {
+	if got := ParseCoder(nil); got.Code() != UnknownCode {
+		t.Fatalf("ParseCoder(nil) = %+v, want UnknownCode", got)
+	}
+	if got := ParseCoder(errors.New("plain error with no business code")); got.Code() != UnknownCode {
+		t.Fatalf("ParseCoder(non-wire error) = %+v, want UnknownCode", got)
+	}
+}
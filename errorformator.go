@@ -1,14 +1,12 @@
 package errorformator
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
-	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/sigurn/crc8"
@@ -23,8 +21,8 @@ const (
 )
 
 type ErrorFormator struct {
-	Filename      string `json:"filename"`
-	mutex         sync.Mutex
+	store         Store
+	Sinks         []AuditSink
 	Separator     byte   `json:"Separator"`
 	WithCallChain bool   `json:"withCallChain"`
 	Skip          int    `json:"skip"`
@@ -40,26 +38,33 @@ type StackTracer interface {
 	StackTrace() errors.StackTrace
 }
 
+// New builds an ErrorFormator backed by a JSONFileStore at fileName, kept
+// for callers that only need the default on-disk map. Pass fileName ""
+// to skip persistence entirely. For SQLite or any other backend, build a
+// Store directly and use NewWithStore.
 func New(fileName string) (errorFormator *ErrorFormator, err error) {
-	err = Mkdir(filepath.Dir(fileName))
-	if err != nil {
-		return
-	}
-	if !IsExist(fileName) { // check file permision
-		f, err := os.Create(fileName)
-		if err != nil {
-			return nil, err
-		}
-		f.Close()
-		fd, err := os.Open(fileName)
+	var store Store
+	if fileName == "" {
+		store = NewNoopStore()
+	} else {
+		store, err = NewJSONFileStore(fileName)
 		if err != nil {
-			return nil, err
+			return
 		}
-		fd.Close()
+	}
+	return NewWithStore(store)
+}
+
+// NewWithStore builds an ErrorFormator backed by an arbitrary Store, letting
+// callers plug in SQLiteStore, NoopStore, or their own implementation. A nil
+// store behaves like NoopStore.
+func NewWithStore(store Store) (errorFormator *ErrorFormator, err error) {
+	if store == nil {
+		store = NewNoopStore()
 	}
 	packageName, _ := GetModuleName(MOD_FILE_DEFAULT)
 	errorFormator = &ErrorFormator{
-		Filename:      fileName,
+		store:         store,
 		Separator:     SEPARATOR_DEFAULT,
 		WithCallChain: WITH_CALL_CHAIN,
 		Skip:          SKIP_DEFAULT,
@@ -68,20 +73,33 @@ func New(fileName string) (errorFormator *ErrorFormator, err error) {
 	return
 }
 
-//FormatError generate format error message
-func (errorFormator *ErrorFormator) FormatMsg(msg string, args ...int) (err error) {
+// AddSink registers an AuditSink. FormatMsgCtx and FormatErrorCtx fan the
+// resulting AuditEvent out to every registered sink.
+func (errorFormator *ErrorFormator) AddSink(sink AuditSink) {
+	errorFormator.Sinks = append(errorFormator.Sinks, sink)
+}
+
+// Close flushes and releases the underlying Store. Callers that built their
+// ErrorFormator with New or NewWithStore should call Close before exiting,
+// since JSONFileStore only otherwise persists a newly observed business code
+// once its coalescing timer fires.
+func (errorFormator *ErrorFormator) Close() error {
+	return errorFormator.store.Close()
+}
+
+//FormatMsg generate format error message. Passing a Coder pins the wire
+//business code, HTTP status, and reference URL to that registration; with no
+//Coder the call site's business code is derived from the stack frame and, on
+//first observation, auto-registered with a default Coder so later calls to
+//ParseCoder can still resolve it.
+func (errorFormator *ErrorFormator) FormatMsg(msg string, coder ...Coder) (err error) {
 	httpCode := 500
-	businessCode := "000000000"
-	formatTpl := "%c%d:%s%c%s"
-	if len(args) >= 2 {
-		httpCode = args[0]
-		businessCode = strconv.Itoa(args[1])
-		err = fmt.Errorf(formatTpl, errorFormator.Separator, httpCode, businessCode, errorFormator.Separator, msg)
+	formatTpl := "%c%d:%d%c%s"
+	if len(coder) > 0 {
+		c := coder[0]
+		err = fmt.Errorf(formatTpl, errorFormator.Separator, c.HTTPStatus(), c.Code(), errorFormator.Separator, msg)
 		return
 	}
-	if len(args) == 1 {
-		httpCode = args[0]
-	}
 	if !errorFormator.WithCallChain { // Detect whether it is in target format
 		if msg[0] == byte(errorFormator.Separator) {
 			return fmt.Errorf(msg)
@@ -92,21 +110,83 @@ func (errorFormator *ErrorFormator) FormatMsg(msg string, args ...int) (err erro
 	n := runtime.Callers(errorFormator.Skip, pcArr)
 	frames := runtime.CallersFrames(pcArr[:n])
 	businessCode, funcName, line := errorFormator.ParseFrames(frames)
-	if errorFormator.Filename != "" {
-		errMap := &ErrMap{
-			BusinessCode: businessCode,
-			Package:      packageName,
-			FunctionName: funcName,
-			Line:         strconv.Itoa(line),
+	code := businessCodeToInt(businessCode)
+	httpCode = registerDefault(code, httpCode, msg).HTTPStatus()
+	errMap := &ErrMap{
+		BusinessCode: businessCode,
+		Package:      packageName,
+		FunctionName: funcName,
+		Line:         strconv.Itoa(line),
+	}
+	errorFormator.updateMapFile(errMap)
+	err = fmt.Errorf(formatTpl, errorFormator.Separator, httpCode, code, errorFormator.Separator, msg)
+	return
+}
+
+//FormatMsgCtx is FormatMsg's context-aware counterpart: it emits an
+//AuditEvent carrying fields (request ID, user ID, remote IP, ...) to every
+//registered Sink, so operators have a single stream tying the business code
+//back to the request that produced it.
+func (errorFormator *ErrorFormator) FormatMsgCtx(ctx context.Context, msg string, fields ...Field) (err error) {
+	httpCode := 500
+	formatTpl := "%c%d:%d%c%s"
+	if !errorFormator.WithCallChain { // Detect whether it is in target format
+		if msg[0] == byte(errorFormator.Separator) {
+			return fmt.Errorf(msg)
 		}
-		go errorFormator.updateMapFile(errMap)
 	}
-	err = fmt.Errorf(formatTpl, errorFormator.Separator, httpCode, businessCode, errorFormator.Separator, msg)
+
+	pcArr := make([]uintptr, 32) // at least 1 entry needed
+	n := runtime.Callers(errorFormator.Skip, pcArr)
+	frames := runtime.CallersFrames(pcArr[:n])
+	businessCode, funcName, line := errorFormator.ParseFrames(frames)
+	code := businessCodeToInt(businessCode)
+	httpCode = registerDefault(code, httpCode, msg).HTTPStatus()
+	errMap := &ErrMap{
+		BusinessCode: businessCode,
+		Package:      packageName,
+		FunctionName: funcName,
+		Line:         strconv.Itoa(line),
+	}
+	errorFormator.updateMapFile(errMap)
+	errorFormator.emitAudit(ctx, code, httpCode, packageName, funcName, errMap.Line, msg, fields)
+	err = fmt.Errorf(formatTpl, errorFormator.Separator, httpCode, code, errorFormator.Separator, msg)
 	return
 }
 
-func (errorFormator *ErrorFormator) FormatError(err error) (newErr error) {
+//FormatError wraps err into a *FormattedError. Passing a Coder pins the wire
+//business code and HTTP status to that registration; with no Coder the
+//business code is derived from err's own stack trace (or, absent one, the
+//caller's) and auto-registered on first observation, same as FormatMsg.
+//Re-formatting an already-formatted error is idempotent: it keeps the
+//original business code, Coder and stack rather than re-parsing frames.
+func (errorFormator *ErrorFormator) FormatError(err error, coder ...Coder) (newErr *FormattedError) {
+	if err == nil {
+		return nil
+	}
+	formatTpl := "%c%d:%d%c%s"
+	if already, ok := AsFormatted(err); ok {
+		// True no-op: reuse already as-is instead of wrapping its wire
+		// string again, which would nest another "#httpCode:code#" prefix
+		// onto it every time FormatError is called on its own output.
+		newErr = already
+		return
+	}
+
 	httpCode := 500
+	if len(coder) > 0 {
+		c := coder[0]
+		newErr = &FormattedError{
+			err:          err,
+			BusinessCode: c.Code(),
+			HTTPCode:     c.HTTPStatus(),
+			Coder:        c,
+			stack:        extractStack(err),
+		}
+		newErr.wire = fmt.Sprintf(formatTpl, errorFormator.Separator, newErr.HTTPCode, newErr.BusinessCode, errorFormator.Separator, err.Error())
+		return
+	}
+
 	pcArr := make([]uintptr, 32) // at least 1 entry needed
 	var frames *runtime.Frames
 	n := 0
@@ -124,20 +204,93 @@ func (errorFormator *ErrorFormator) FormatError(err error) (newErr error) {
 	}
 	frames = runtime.CallersFrames(pcArr[:n])
 	businessCode, funcName, line := errorFormator.ParseFrames(frames)
-	if errorFormator.Filename != "" {
-		errMap := &ErrMap{
-			BusinessCode: businessCode,
-			Package:      packageName,
-			FunctionName: funcName,
-			Line:         strconv.Itoa(line),
+	code := businessCodeToInt(businessCode)
+	registeredCoder := registerDefault(code, httpCode, err.Error())
+	httpCode = registeredCoder.HTTPStatus()
+	errMap := &ErrMap{
+		BusinessCode: businessCode,
+		Package:      packageName,
+		FunctionName: funcName,
+		Line:         strconv.Itoa(line),
+	}
+	errorFormator.updateMapFile(errMap)
+	newErr = &FormattedError{
+		err:          err,
+		BusinessCode: code,
+		HTTPCode:     httpCode,
+		Coder:        registeredCoder,
+		stack:        extractStack(err),
+	}
+	newErr.wire = fmt.Sprintf(formatTpl, errorFormator.Separator, httpCode, code, errorFormator.Separator, err.Error())
+	return
+}
+
+//FormatErrorCtx is FormatError's context-aware counterpart: it emits an
+//AuditEvent carrying fields (request ID, user ID, remote IP, ...) to every
+//registered Sink.
+func (errorFormator *ErrorFormator) FormatErrorCtx(ctx context.Context, err error, fields ...Field) (newErr *FormattedError) {
+	if err == nil {
+		return nil
+	}
+	formatTpl := "%c%d:%d%c%s"
+	if already, ok := AsFormatted(err); ok {
+		// True no-op: reuse already as-is, same as FormatError, but still
+		// emit this call's audit event since its fields are call-specific.
+		newErr = already
+		errorFormator.emitAudit(ctx, already.BusinessCode, already.HTTPCode, "", "", "", already.Error(), fields)
+		return
+	}
+
+	httpCode := 500
+	pcArr := make([]uintptr, 32) // at least 1 entry needed
+	var frames *runtime.Frames
+	n := 0
+	stackErr, ok := err.(StackTracer)
+	if ok {
+		stack := stackErr.StackTrace()
+		n = len(stack)
+		for i, frame := range stack {
+			pc := uintptr(frame) - 1
+			pcArr[i] = pc
 		}
-		errorFormator.updateMapFile(errMap)
+	} else {
+		n = runtime.Callers(errorFormator.Skip, pcArr)
+
+	}
+	frames = runtime.CallersFrames(pcArr[:n])
+	businessCode, funcName, line := errorFormator.ParseFrames(frames)
+	code := businessCodeToInt(businessCode)
+	registeredCoder := registerDefault(code, httpCode, err.Error())
+	httpCode = registeredCoder.HTTPStatus()
+	errMap := &ErrMap{
+		BusinessCode: businessCode,
+		Package:      packageName,
+		FunctionName: funcName,
+		Line:         strconv.Itoa(line),
 	}
-	formatTpl := "%c%d:%s%c%w"
-	newErr = fmt.Errorf(formatTpl, errorFormator.Separator, httpCode, businessCode, errorFormator.Separator, err)
+	errorFormator.updateMapFile(errMap)
+	newErr = &FormattedError{
+		err:          err,
+		BusinessCode: code,
+		HTTPCode:     httpCode,
+		Coder:        registeredCoder,
+		stack:        extractStack(err),
+	}
+	newErr.wire = fmt.Sprintf(formatTpl, errorFormator.Separator, httpCode, code, errorFormator.Separator, err.Error())
+	errorFormator.emitAudit(ctx, code, httpCode, packageName, funcName, errMap.Line, err.Error(), fields)
 	return
 }
 
+// businessCodeToInt converts the CRC-derived business code string produced by
+// ParseFrames into the int used as a Coder's registry key.
+func businessCodeToInt(businessCode string) int {
+	code, err := strconv.Atoi(businessCode)
+	if err != nil {
+		return UnknownCode
+	}
+	return code
+}
+
 func (errorFormator *ErrorFormator) ParseFrames(frames *runtime.Frames) (businessCode string, funcName string, line int) {
 	fullname := ""
 	for {
@@ -165,35 +318,13 @@ func (errorFormator *ErrorFormator) ParseFrames(frames *runtime.Frames) (busines
 	return
 }
 
+// updateMapFile records errMap's call site in the formator's Store the
+// first time its business code is observed.
 func (errorFormator *ErrorFormator) updateMapFile(errMap *ErrMap) (err error) {
-	errorFormator.mutex.Lock()
-	defer errorFormator.mutex.Unlock()
-	b, err := os.ReadFile(errorFormator.Filename)
-	if err != nil {
-		return
-	}
-	errMapTable := map[string]*ErrMap{}
-	if len(b) > 0 {
-		err = json.Unmarshal(b, &errMapTable)
-		if err != nil {
-			return
-		}
-	}
-
-	_, ok := errMapTable[errMap.BusinessCode]
-	if ok {
-		return
-	}
-	errMapTable[errMap.BusinessCode] = errMap
-	jsonByte, err := json.Marshal(errMapTable)
-	if err != nil {
-		return
-	}
-	err = os.WriteFile(errorFormator.Filename, jsonByte, os.ModePerm)
-	if err != nil {
+	if errorFormator.store.Has(errMap.BusinessCode) {
 		return
 	}
-	return
+	return errorFormator.store.Put(errMap.BusinessCode, errMap)
 }
 
 func IsExist(path string) bool {
@@ -213,6 +344,7 @@ func Mkdir(filePath string) error {
 
 var packageName, _ = GetModuleName(MOD_FILE_DEFAULT)
 var defaultErrorFormator = &ErrorFormator{
+	store:         NewNoopStore(),
 	Separator:     SEPARATOR_DEFAULT,
 	WithCallChain: WITH_CALL_CHAIN,
 	Skip:          3,
@@ -220,13 +352,23 @@ var defaultErrorFormator = &ErrorFormator{
 }
 
 //Format format the error
-func Format(msg string, args ...int) (err error) {
-	err = defaultErrorFormator.FormatMsg(msg, args...)
+func Format(msg string, coder ...Coder) (err error) {
+	err = defaultErrorFormator.FormatMsg(msg, coder...)
+	return
+}
+
+func FormatError(err error, coder ...Coder) (newErr *FormattedError) {
+	newErr = defaultErrorFormator.FormatError(err, coder...)
+	return
+}
+
+func FormatMsgCtx(ctx context.Context, msg string, fields ...Field) (err error) {
+	err = defaultErrorFormator.FormatMsgCtx(ctx, msg, fields...)
 	return
 }
 
-func FormatError(err error) (newErr error) {
-	newErr = defaultErrorFormator.FormatError(err)
+func FormatErrorCtx(ctx context.Context, err error, fields ...Field) (newErr *FormattedError) {
+	newErr = defaultErrorFormator.FormatErrorCtx(ctx, err, fields...)
 	return
 }
 
@@ -0,0 +1,108 @@
+package errorformator
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// FormattedError is the concrete error FormatError returns. Unlike the bare
+// fmt.Errorf("%w", err) it replaces, it keeps the original error reachable
+// through Unwrap and, when the original carried a pkg/errors stack, keeps
+// that stack reachable through StackTrace, so printing fe.StackTrace() with
+// %+v shows the real frames instead of the ones FormatError itself ran on.
+// FormattedError has no Format method: %+v on the error itself prints the
+// same text as Error().
+type FormattedError struct {
+	err          error
+	wire         string
+	BusinessCode int
+	HTTPCode     int
+	Coder        Coder
+	stack        errors.StackTrace
+}
+
+func (fe *FormattedError) Error() string {
+	return fe.wire
+}
+
+// Unwrap exposes the original error to errors.Is/errors.As.
+func (fe *FormattedError) Unwrap() error {
+	return fe.err
+}
+
+// StackTrace implements StackTracer. It returns the original error's stack
+// when it had one, so re-formatting an error never loses its real frames.
+func (fe *FormattedError) StackTrace() errors.StackTrace {
+	return fe.stack
+}
+
+// Is reports whether target is the sentinel for fe's business code, letting
+// callers write errors.Is(err, errorformator.SentinelForCode(code)).
+func (fe *FormattedError) Is(target error) bool {
+	sentinel, ok := target.(*codeSentinel)
+	return ok && sentinel.code == fe.BusinessCode
+}
+
+// AsFormatted unwraps err looking for a *FormattedError, the same way
+// errors.As would.
+func AsFormatted(err error) (*FormattedError, bool) {
+	var fe *FormattedError
+	if errors.As(err, &fe) {
+		return fe, true
+	}
+	return nil, false
+}
+
+// NewFormattedError builds a *FormattedError directly from its wire fields,
+// for transport adapters (grpcerr, httperr) that reconstruct one from a
+// status or response on the caller's side rather than from a live Go error
+// with its own stack.
+func NewFormattedError(err error, businessCode int, httpCode int, coder Coder) *FormattedError {
+	fe := &FormattedError{
+		err:          err,
+		wire:         err.Error(),
+		BusinessCode: businessCode,
+		HTTPCode:     httpCode,
+		Coder:        coder,
+		stack:        extractStack(err),
+	}
+	return fe
+}
+
+type codeSentinel struct {
+	code int
+}
+
+func (s *codeSentinel) Error() string {
+	return fmt.Sprintf("errorformator: business code %d", s.code)
+}
+
+var (
+	sentinelMu    sync.Mutex
+	sentinelTable = map[int]*codeSentinel{}
+)
+
+// SentinelForCode returns a stable error value for code so callers can write
+// errors.Is(err, errorformator.SentinelForCode(code)) without needing the
+// concrete *FormattedError that produced err.
+func SentinelForCode(code int) error {
+	sentinelMu.Lock()
+	defer sentinelMu.Unlock()
+	if s, ok := sentinelTable[code]; ok {
+		return s
+	}
+	s := &codeSentinel{code: code}
+	sentinelTable[code] = s
+	return s
+}
+
+// extractStack returns err's pkg/errors stack trace when it has one.
+func extractStack(err error) errors.StackTrace {
+	stackErr, ok := err.(StackTracer)
+	if !ok {
+		return nil
+	}
+	return stackErr.StackTrace()
+}
@@ -0,0 +1,90 @@
+package errorformator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestFormatErrorIsIdempotent(t *testing.T) {
+	formator, err := NewWithStore(NewNoopStore())
+	if err != nil {
+		t.Fatalf("NewWithStore: %v", err)
+	}
+	coder := NewCoder(123456, 500, "boom", "")
+
+	once := formator.FormatError(errors.New("boom"), coder)
+	twice := formator.FormatError(once, coder)
+	thrice := formator.FormatError(twice, coder)
+
+	if twice != once {
+		t.Fatalf("FormatError on an already-formatted error should return it unchanged, got a new value")
+	}
+	if thrice != once {
+		t.Fatalf("FormatError should be idempotent across repeated calls")
+	}
+	if once.Error() != twice.Error() || twice.Error() != thrice.Error() {
+		t.Fatalf("wire string grew across re-formatting: %q, %q, %q", once.Error(), twice.Error(), thrice.Error())
+	}
+}
+
+func TestFormatErrorCtxIsIdempotent(t *testing.T) {
+	formator, err := NewWithStore(NewNoopStore())
+	if err != nil {
+		t.Fatalf("NewWithStore: %v", err)
+	}
+	ctx := context.Background()
+
+	once := formator.FormatErrorCtx(ctx, errors.New("boom"), F("requestID", "abc"))
+	wire := once.Error()
+	twice := formator.FormatErrorCtx(ctx, once, F("requestID", "def"))
+
+	if twice != once {
+		t.Fatalf("FormatErrorCtx on an already-formatted error should return it unchanged")
+	}
+	if twice.Error() != wire {
+		t.Fatalf("wire string changed on re-format: %q != %q", twice.Error(), wire)
+	}
+}
+
+func TestFormatErrorNilIsNilSafe(t *testing.T) {
+	formator, err := NewWithStore(NewNoopStore())
+	if err != nil {
+		t.Fatalf("NewWithStore: %v", err)
+	}
+	if got := formator.FormatError(nil); got != nil {
+		t.Fatalf("FormatError(nil) = %v, want nil", got)
+	}
+	if got := formator.FormatErrorCtx(context.Background(), nil); got != nil {
+		t.Fatalf("FormatErrorCtx(ctx, nil) = %v, want nil", got)
+	}
+}
+
+func TestFormattedErrorUnwrapAndIs(t *testing.T) {
+	formator, err := NewWithStore(NewNoopStore())
+	if err != nil {
+		t.Fatalf("NewWithStore: %v", err)
+	}
+	coder := NewCoder(345678, 404, "not found", "")
+	original := errors.New("missing")
+
+	fe := formator.FormatError(original, coder)
+	if !errors.Is(fe, original) {
+		t.Fatalf("errors.Is(fe, original) = false, want true")
+	}
+	if !errors.Is(fe, SentinelForCode(345678)) {
+		t.Fatalf("errors.Is(fe, SentinelForCode(345678)) = false, want true")
+	}
+	if errors.Is(fe, SentinelForCode(999999)) {
+		t.Fatalf("errors.Is(fe, SentinelForCode(999999)) = true, want false")
+	}
+
+	var as *FormattedError
+	if !errors.As(fmt.Errorf("wrapped: %w", fe), &as) {
+		t.Fatalf("errors.As should find the wrapped *FormattedError")
+	}
+	if as != fe {
+		t.Fatalf("errors.As returned a different *FormattedError than the one wrapped")
+	}
+}
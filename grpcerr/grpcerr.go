@@ -0,0 +1,137 @@
+// Package grpcerr adapts errorformator's *FormattedError to gRPC's
+// codes.Code and google.rpc.Status, so a business code, HTTP status, and
+// reference URL survive a gRPC hop in both directions.
+package grpcerr
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/suifengpiao14/formatgoerr"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorInfoDomain tags every ErrorInfo detail this package attaches, so a
+// client can tell errorformator's convention apart from another service's.
+const errorInfoDomain = "errorformator"
+
+// CoderToCode maps the HTTP status carried by a FormattedError to the
+// closest gRPC code. ToStatus and both interceptors always use this mapping.
+func CoderToCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case 400:
+		return codes.InvalidArgument
+	case 401:
+		return codes.Unauthenticated
+	case 403:
+		return codes.PermissionDenied
+	case 404:
+		return codes.NotFound
+	case 408:
+		return codes.DeadlineExceeded
+	case 409:
+		return codes.AlreadyExists
+	case 429:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case 501:
+		return codes.Unimplemented
+	case 503:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// ToStatus converts fe into a gRPC status carrying an errdetails.ErrorInfo
+// whose Reason is the business code and whose Metadata holds the HTTP status
+// and, when fe has a Coder, its message and reference URL. The call site's
+// package and function are not included.
+func ToStatus(fe *errorformator.FormattedError) *status.Status {
+	message := fe.Error()
+	if fe.Coder != nil {
+		message = fe.Coder.String()
+	}
+	st := status.New(CoderToCode(fe.HTTPCode), message)
+	info := &errdetails.ErrorInfo{
+		Reason: strconv.Itoa(fe.BusinessCode),
+		Domain: errorInfoDomain,
+		Metadata: map[string]string{
+			"httpCode": strconv.Itoa(fe.HTTPCode),
+		},
+	}
+	if fe.Coder != nil {
+		info.Metadata["message"] = fe.Coder.String()
+		info.Metadata["reference"] = fe.Coder.Reference()
+	}
+	withDetails, err := st.WithDetails(info)
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// formatIfNeeded returns err as-is when it is already a *FormattedError and
+// otherwise runs it through formator.FormatError first.
+func formatIfNeeded(formator *errorformator.ErrorFormator, err error) *errorformator.FormattedError {
+	if fe, ok := errorformator.AsFormatted(err); ok {
+		return fe
+	}
+	return formator.FormatError(err)
+}
+
+// UnaryServerInterceptor formats any error a unary handler returns and
+// replaces it with its gRPC status equivalent.
+func UnaryServerInterceptor(formator *errorformator.ErrorFormator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		resp, err = handler(ctx, req)
+		if err == nil {
+			return
+		}
+		return resp, ToStatus(formatIfNeeded(formator, err)).Err()
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor's streaming
+// counterpart.
+func StreamServerInterceptor(formator *errorformator.ErrorFormator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		err := handler(srv, ss)
+		if err == nil {
+			return nil
+		}
+		return ToStatus(formatIfNeeded(formator, err)).Err()
+	}
+}
+
+// FromGRPCStatus reconstructs a *errorformator.FormattedError from err's
+// gRPC status, so errors.As works on the caller's side of the wire. It
+// returns false when err carries no status or no ErrorInfo detail from this
+// package.
+func FromGRPCStatus(err error) (*errorformator.FormattedError, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil, false
+	}
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.Domain != errorInfoDomain {
+			continue
+		}
+		code, convErr := strconv.Atoi(info.Reason)
+		if convErr != nil {
+			continue
+		}
+		httpCode, convErr := strconv.Atoi(info.Metadata["httpCode"])
+		if convErr != nil {
+			httpCode = int(st.Code())
+		}
+		coder := errorformator.NewCoder(code, httpCode, info.Metadata["message"], info.Metadata["reference"])
+		return errorformator.NewFormattedError(st.Err(), code, httpCode, coder), true
+	}
+	return nil, false
+}
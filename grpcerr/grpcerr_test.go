@@ -0,0 +1,52 @@
+package grpcerr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/suifengpiao14/formatgoerr"
+)
+
+func TestToStatusUsesCoderMessage(t *testing.T) {
+	coder := errorformator.NewCoder(520001, 404, "human readable message", "https://example.com/520001")
+	fe := errorformator.NewFormattedError(errors.New("#404:520001#human readable message"), 520001, 404, coder)
+
+	st := ToStatus(fe)
+	if st.Message() != "human readable message" {
+		t.Fatalf("ToStatus message = %q, want the Coder's human message", st.Message())
+	}
+	if st.Code() != CoderToCode(404) {
+		t.Fatalf("ToStatus code = %v, want %v", st.Code(), CoderToCode(404))
+	}
+}
+
+func TestToStatusFallsBackToErrorWithoutCoder(t *testing.T) {
+	fe := errorformator.NewFormattedError(errors.New("#500:1#boom"), 1, 500, nil)
+	st := ToStatus(fe)
+	if st.Message() != fe.Error() {
+		t.Fatalf("ToStatus message = %q, want fe.Error() %q", st.Message(), fe.Error())
+	}
+}
+
+func TestFromGRPCStatusRoundTrips(t *testing.T) {
+	coder := errorformator.NewCoder(520002, 409, "conflict", "https://example.com/520002")
+	fe := errorformator.NewFormattedError(errors.New("#409:520002#conflict"), 520002, 409, coder)
+
+	err := ToStatus(fe).Err()
+	got, ok := FromGRPCStatus(err)
+	if !ok {
+		t.Fatalf("FromGRPCStatus should find the ErrorInfo detail ToStatus attached")
+	}
+	if got.BusinessCode != 520002 || got.HTTPCode != 409 {
+		t.Fatalf("FromGRPCStatus = %+v, want BusinessCode 520002 HTTPCode 409", got)
+	}
+	if got.Coder == nil || got.Coder.String() != "conflict" || got.Coder.Reference() != "https://example.com/520002" {
+		t.Fatalf("FromGRPCStatus Coder = %+v, want message %q reference %q", got.Coder, "conflict", "https://example.com/520002")
+	}
+}
+
+func TestFromGRPCStatusFalseWithoutDetails(t *testing.T) {
+	if _, ok := FromGRPCStatus(errors.New("plain error")); ok {
+		t.Fatalf("FromGRPCStatus(plain error) should report false")
+	}
+}
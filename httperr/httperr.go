@@ -0,0 +1,100 @@
+// Package httperr adapts errorformator's *FormattedError to HTTP: a
+// WriteError helper and server middleware that write {code, message,
+// reference, request_id} JSON with the matching status, a Recoverer that
+// turns panics into formatted errors, and a client-side FromHTTPResponse
+// that reconstructs a *FormattedError so errors.As works across the wire.
+package httperr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/suifengpiao14/formatgoerr"
+)
+
+// Body is the wire shape WriteError sends and FromHTTPResponse parses.
+type Body struct {
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	Reference string `json:"reference"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// WriteError formats err (through formator if it isn't already a
+// *errorformator.FormattedError) and writes it as {code, message,
+// reference, request_id} JSON with the matching HTTP status.
+func WriteError(w http.ResponseWriter, r *http.Request, formator *errorformator.ErrorFormator, err error) {
+	fe, ok := errorformator.AsFormatted(err)
+	if !ok {
+		fe = formator.FormatError(err)
+	}
+	body := Body{
+		Code:      fe.BusinessCode,
+		Message:   fe.Error(),
+		RequestID: r.Header.Get("X-Request-Id"),
+	}
+	if fe.Coder != nil {
+		body.Message = fe.Coder.String()
+		body.Reference = fe.Coder.Reference()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(fe.HTTPCode)
+	json.NewEncoder(w).Encode(body)
+}
+
+type errCtxKey struct{}
+
+// WithError records err for the enclosing Middleware to pick up and write
+// once the handler chain returns. Outside Middleware it records nothing.
+func WithError(r *http.Request, err error) *http.Request {
+	if slot, ok := r.Context().Value(errCtxKey{}).(*error); ok {
+		*slot = err
+	}
+	return r
+}
+
+// Middleware writes the response WriteError produces for any error a
+// downstream handler attached with WithError.
+func Middleware(formator *errorformator.ErrorFormator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var err error
+			r = r.WithContext(context.WithValue(r.Context(), errCtxKey{}, &err))
+			next.ServeHTTP(w, r)
+			if err != nil {
+				WriteError(w, r, formator, err)
+			}
+		})
+	}
+}
+
+// Recoverer turns a panic in next into a formatted error, with the panic
+// site's own frame as the business code, and writes it as the response
+// instead of crashing the server.
+func Recoverer(formator *errorformator.ErrorFormator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				if rec := recover(); rec != nil {
+					WriteError(w, r, formator, formator.FormatError(fmt.Errorf("panic: %v", rec)))
+				}
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// FromHTTPResponse reconstructs a *errorformator.FormattedError from a
+// response WriteError produced, so errors.As works on the caller's side of
+// the wire.
+func FromHTTPResponse(resp *http.Response) (*errorformator.FormattedError, error) {
+	defer resp.Body.Close()
+	var body Body
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	coder := errorformator.NewCoder(body.Code, resp.StatusCode, body.Message, body.Reference)
+	return errorformator.NewFormattedError(fmt.Errorf(body.Message), body.Code, resp.StatusCode, coder), nil
+}
@@ -0,0 +1,57 @@
+package httperr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/suifengpiao14/formatgoerr"
+)
+
+func TestWriteErrorUsesCoderMessage(t *testing.T) {
+	formator, err := errorformator.NewWithStore(errorformator.NewNoopStore())
+	if err != nil {
+		t.Fatalf("NewWithStore: %v", err)
+	}
+	coder := errorformator.NewCoder(530001, 404, "not found", "https://example.com/530001")
+	fe := errorformator.NewFormattedError(errors.New("#404:530001#not found"), 530001, 404, coder)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Request-Id", "req-1")
+	WriteError(rec, req, formator, fe)
+
+	if rec.Code != 404 {
+		t.Fatalf("WriteError status = %d, want 404", rec.Code)
+	}
+	var body Body
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Message != "not found" || body.Reference != "https://example.com/530001" || body.RequestID != "req-1" {
+		t.Fatalf("body = %+v, want Coder message/reference and the request ID header", body)
+	}
+}
+
+func TestFromHTTPResponseRoundTrips(t *testing.T) {
+	formator, err := errorformator.NewWithStore(errorformator.NewNoopStore())
+	if err != nil {
+		t.Fatalf("NewWithStore: %v", err)
+	}
+	coder := errorformator.NewCoder(530002, 409, "conflict", "https://example.com/530002")
+	fe := errorformator.NewFormattedError(errors.New("#409:530002#conflict"), 530002, 409, coder)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	WriteError(rec, req, formator, fe)
+
+	resp := rec.Result()
+	got, err := FromHTTPResponse(resp)
+	if err != nil {
+		t.Fatalf("FromHTTPResponse: %v", err)
+	}
+	if got.BusinessCode != 530002 || got.HTTPCode != 409 {
+		t.Fatalf("FromHTTPResponse = %+v, want BusinessCode 530002 HTTPCode 409", got)
+	}
+}
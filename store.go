@@ -0,0 +1,275 @@
+package errorformator
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofrs/flock"
+	_ "modernc.org/sqlite"
+)
+
+// Store persists the business-code-to-call-site map that FormatMsg and
+// FormatError discover as the program runs. Has/Put must be safe for
+// concurrent use from multiple goroutines; JSONFileStore is additionally
+// safe across multiple OS processes sharing the same file.
+type Store interface {
+	Has(code string) bool
+	Put(code string, errMap *ErrMap) error
+	All() (map[string]*ErrMap, error)
+	Close() error
+}
+
+// NoopStore discards every Put. Useful for tests and for callers that only
+// want the wire-format encoding without the call-site map on disk.
+type NoopStore struct{}
+
+func NewNoopStore() *NoopStore { return &NoopStore{} }
+
+func (*NoopStore) Has(code string) bool                  { return false }
+func (*NoopStore) Put(code string, errMap *ErrMap) error { return nil }
+func (*NoopStore) All() (map[string]*ErrMap, error)      { return map[string]*ErrMap{}, nil }
+func (*NoopStore) Close() error                          { return nil }
+
+// jsonFileFlushInterval bounds how long a newly observed code can sit in
+// memory before JSONFileStore coalesces it to disk.
+const jsonFileFlushInterval = 200 * time.Millisecond
+
+// JSONFileStore keeps the full map in memory and only touches disk when a
+// new code appears, batching bursts of new codes into a single write rather
+// than spawning a goroutine per error. Writes go through os.CreateTemp plus
+// os.Rename so a crash mid-write can never leave a truncated file, and an
+// flock-based lock file keeps multiple processes sharing Filename from
+// racing each other.
+type JSONFileStore struct {
+	Filename string
+
+	mu    sync.RWMutex
+	cache map[string]*ErrMap
+	lock  *flock.Flock
+
+	flushMu    sync.Mutex
+	dirty      bool
+	flushTimer *time.Timer
+}
+
+func NewJSONFileStore(filename string) (store *JSONFileStore, err error) {
+	if err = Mkdir(filepath.Dir(filename)); err != nil {
+		return
+	}
+	if !IsExist(filename) {
+		f, createErr := os.Create(filename)
+		if createErr != nil {
+			err = createErr
+			return
+		}
+		f.Close()
+	}
+	store = &JSONFileStore{
+		Filename: filename,
+		cache:    map[string]*ErrMap{},
+		lock:     flock.New(filename + ".lock"),
+	}
+	err = store.load()
+	return
+}
+
+func (s *JSONFileStore) load() error {
+	if err := s.lock.RLock(); err != nil {
+		return err
+	}
+	defer s.lock.Unlock()
+	b, err := os.ReadFile(s.Filename)
+	if err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	table := map[string]*ErrMap{}
+	if err := json.Unmarshal(b, &table); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.cache = table
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *JSONFileStore) Has(code string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.cache[code]
+	return ok
+}
+
+func (s *JSONFileStore) Put(code string, errMap *ErrMap) error {
+	s.mu.Lock()
+	if _, ok := s.cache[code]; ok {
+		s.mu.Unlock()
+		return nil
+	}
+	s.cache[code] = errMap
+	s.mu.Unlock()
+	s.scheduleFlush()
+	return nil
+}
+
+func (s *JSONFileStore) All() (map[string]*ErrMap, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]*ErrMap, len(s.cache))
+	for code, errMap := range s.cache {
+		out[code] = errMap
+	}
+	return out, nil
+}
+
+// scheduleFlush coalesces bursts of new codes into a single write, instead
+// of the per-error goroutine the old updateMapFile spawned.
+func (s *JSONFileStore) scheduleFlush() {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+	s.dirty = true
+	if s.flushTimer != nil {
+		return
+	}
+	s.flushTimer = time.AfterFunc(jsonFileFlushInterval, s.flush)
+}
+
+func (s *JSONFileStore) flush() {
+	s.flushMu.Lock()
+	s.flushTimer = nil
+	if !s.dirty {
+		s.flushMu.Unlock()
+		return
+	}
+	s.dirty = false
+	s.flushMu.Unlock()
+
+	if err := s.lock.Lock(); err != nil {
+		return
+	}
+	defer s.lock.Unlock()
+
+	// Re-read and merge so a concurrent process's writes since our last
+	// load aren't clobbered by this one.
+	onDisk := map[string]*ErrMap{}
+	if b, err := os.ReadFile(s.Filename); err == nil && len(b) > 0 {
+		json.Unmarshal(b, &onDisk)
+	}
+	s.mu.RLock()
+	for code, errMap := range s.cache {
+		onDisk[code] = errMap
+	}
+	s.mu.RUnlock()
+
+	b, err := json.Marshal(onDisk)
+	if err != nil {
+		return
+	}
+	if err := s.atomicWrite(b); err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	// Re-merge: a Put landing between the RUnlock above and this Lock wrote
+	// into the old cache map, which onDisk doesn't contain. Folding it in
+	// here, rather than replacing s.cache outright, keeps that entry instead
+	// of silently dropping it (it'll reach disk on the next flush, already
+	// scheduled by that Put's own scheduleFlush call).
+	for code, errMap := range s.cache {
+		if _, ok := onDisk[code]; !ok {
+			onDisk[code] = errMap
+		}
+	}
+	s.cache = onDisk
+	s.mu.Unlock()
+}
+
+func (s *JSONFileStore) atomicWrite(b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.Filename), ".errmap-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, s.Filename)
+}
+
+// Close flushes any pending writes and releases the lock file.
+func (s *JSONFileStore) Close() error {
+	s.flush()
+	return s.lock.Close()
+}
+
+// SQLiteStore persists the map in a SQLite database, giving multiple
+// processes a backend with real transactional writes instead of a shared
+// JSON file.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteStore(dataSourceName string) (store *SQLiteStore, err error) {
+	db, err := sql.Open("sqlite", dataSourceName)
+	if err != nil {
+		return
+	}
+	if _, err = db.Exec(`CREATE TABLE IF NOT EXISTS err_map (
+		business_code TEXT PRIMARY KEY,
+		package TEXT NOT NULL,
+		function_name TEXT NOT NULL,
+		line TEXT NOT NULL
+	)`); err != nil {
+		db.Close()
+		return
+	}
+	store = &SQLiteStore{db: db}
+	return
+}
+
+func (s *SQLiteStore) Has(code string) bool {
+	var exists int
+	err := s.db.QueryRow(`SELECT 1 FROM err_map WHERE business_code = ?`, code).Scan(&exists)
+	return err == nil
+}
+
+func (s *SQLiteStore) Put(code string, errMap *ErrMap) error {
+	_, err := s.db.Exec(
+		`INSERT OR IGNORE INTO err_map (business_code, package, function_name, line) VALUES (?, ?, ?, ?)`,
+		code, errMap.Package, errMap.FunctionName, errMap.Line,
+	)
+	return err
+}
+
+func (s *SQLiteStore) All() (map[string]*ErrMap, error) {
+	rows, err := s.db.Query(`SELECT business_code, package, function_name, line FROM err_map`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]*ErrMap{}
+	for rows.Next() {
+		errMap := &ErrMap{}
+		if err := rows.Scan(&errMap.BusinessCode, &errMap.Package, &errMap.FunctionName, &errMap.Line); err != nil {
+			return nil, err
+		}
+		out[errMap.BusinessCode] = errMap
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
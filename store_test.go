@@ -0,0 +1,152 @@
+package errorformator
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNoopStore(t *testing.T) {
+	store := NewNoopStore()
+	if store.Has("1") {
+		t.Fatalf("NoopStore.Has should always report false")
+	}
+	if err := store.Put("1", &ErrMap{BusinessCode: "1"}); err != nil {
+		t.Fatalf("NoopStore.Put: %v", err)
+	}
+	if store.Has("1") {
+		t.Fatalf("NoopStore.Put should not make Has true")
+	}
+	all, err := store.All()
+	if err != nil || len(all) != 0 {
+		t.Fatalf("NoopStore.All() = %v, %v, want empty map, nil", all, err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("NoopStore.Close: %v", err)
+	}
+}
+
+func TestJSONFileStorePutHasAll(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "errmap.json")
+	store, err := NewJSONFileStore(filename)
+	if err != nil {
+		t.Fatalf("NewJSONFileStore: %v", err)
+	}
+	defer store.Close()
+
+	if store.Has("123") {
+		t.Fatalf("Has should report false for a code never Put")
+	}
+	errMap := &ErrMap{BusinessCode: "123", Package: "pkg", FunctionName: "Fn", Line: "42"}
+	if err := store.Put("123", errMap); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if !store.Has("123") {
+		t.Fatalf("Has should report true right after Put, before the flush timer fires")
+	}
+
+	all, err := store.All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	if got := all["123"]; got == nil || got.FunctionName != "Fn" {
+		t.Fatalf("All()[%q] = %v, want FunctionName \"Fn\"", "123", got)
+	}
+}
+
+func TestJSONFileStorePersistsAcrossClose(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "errmap.json")
+	store, err := NewJSONFileStore(filename)
+	if err != nil {
+		t.Fatalf("NewJSONFileStore: %v", err)
+	}
+	if err := store.Put("456", &ErrMap{BusinessCode: "456", Package: "pkg", FunctionName: "Fn", Line: "1"}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// Close before the coalescing timer would otherwise fire, to prove Close
+	// itself flushes rather than relying on the timer.
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	reopened, err := NewJSONFileStore(filename)
+	if err != nil {
+		t.Fatalf("NewJSONFileStore (reopen): %v", err)
+	}
+	defer reopened.Close()
+	if !reopened.Has("456") {
+		t.Fatalf("code Put before Close should survive a reopen of the same file")
+	}
+}
+
+func TestJSONFileStoreCoalescesFlush(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "errmap.json")
+	store, err := NewJSONFileStore(filename)
+	if err != nil {
+		t.Fatalf("NewJSONFileStore: %v", err)
+	}
+	defer store.Close()
+
+	for i := 0; i < 5; i++ {
+		code := string(rune('a' + i))
+		if err := store.Put(code, &ErrMap{BusinessCode: code}); err != nil {
+			t.Fatalf("Put(%q): %v", code, err)
+		}
+	}
+	time.Sleep(jsonFileFlushInterval * 3)
+
+	reopened, err := NewJSONFileStore(filename)
+	if err != nil {
+		t.Fatalf("NewJSONFileStore (reopen): %v", err)
+	}
+	defer reopened.Close()
+	all, err := reopened.All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	if len(all) != 5 {
+		t.Fatalf("expected all 5 codes from the coalesced flush to persist, got %d", len(all))
+	}
+}
+
+// TestJSONFileStoreFlushDoesNotLoseConcurrentPut drives Put and flush
+// concurrently so a Put landing between flush's read-copy and its final
+// cache reassignment is exercised; before the fix, such a Put's entry was
+// silently dropped once flush replaced s.cache with its own snapshot.
+func TestJSONFileStoreFlushDoesNotLoseConcurrentPut(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "errmap.json")
+	store, err := NewJSONFileStore(filename)
+	if err != nil {
+		t.Fatalf("NewJSONFileStore: %v", err)
+	}
+	defer store.Close()
+
+	const n = 200
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			code := fmt.Sprintf("code-%d", i)
+			store.Put(code, &ErrMap{BusinessCode: code})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			store.flush()
+		}
+	}()
+	wg.Wait()
+	store.Close()
+
+	all, err := store.All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	if len(all) != n {
+		t.Fatalf("expected all %d codes to survive concurrent Put/flush, got %d", n, len(all))
+	}
+}